@@ -0,0 +1,242 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// run execs name with args, bounded by the given timeout (or
+// defaultTimeout, if timeout is zero or negative), reporting combined
+// output on failure.
+func run(timeout time.Duration, dir, name string, args ...string) error {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %v: %w: %s", name, args, err, out)
+	}
+	return nil
+}
+
+type gitBackend struct{}
+
+// reGitSHA matches a full or abbreviated git commit SHA, as opposed to
+// a branch or tag name.
+var reGitSHA = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+// isGitSHA reports whether ref looks like a commit SHA rather than a
+// branch or tag name.
+func isGitSHA(ref string) bool {
+	return reGitSHA.MatchString(ref)
+}
+
+// Clone fetches cloneSpec into dir. When cloneSpec points at a repo on
+// the local filesystem, it first tries a "git archive" fast-path that
+// only transfers subdir at ref, skipping history and every other file
+// in the tree; a remote or otherwise-unarchivable cloneSpec falls back
+// to "git clone", tuned by opts.Depth and opts.SingleBranch. After a
+// "git clone" (not after the archive fast-path), if opts.LFS is set,
+// "git lfs pull" fetches any LFS objects.
+func (gitBackend) Clone(dir, cloneSpec string, opts CloneOptions) error {
+	if isLocalCloneSpec(cloneSpec) {
+		if err := archiveClone(dir, cloneSpec, opts.Ref, opts.Subdir, opts.Timeout); err == nil {
+			return nil
+		}
+	}
+	if err := gitClone(dir, cloneSpec, opts); err != nil {
+		return err
+	}
+	if opts.LFS {
+		return run(opts.Timeout, dir, "git", "lfs", "pull")
+	}
+	return nil
+}
+
+// gitClone runs the plain (non-archive) "git clone" path, shaped by
+// opts.Depth, opts.SingleBranch and opts.Ref. A shallow clone pinned to
+// a bare commit SHA can't be expressed as "git clone --depth", since
+// that flag only shortens history on a branch tip; such a ref is
+// instead fetched directly into an empty repo via shallowFetchSHA.
+func gitClone(dir, cloneSpec string, opts CloneOptions) error {
+	if opts.Depth > 0 && opts.Ref != "" && isGitSHA(opts.Ref) {
+		return shallowFetchSHA(dir, cloneSpec, opts)
+	}
+	args := []string{"clone"}
+	if opts.Depth > 0 {
+		args = append(args, fmt.Sprintf("--depth=%d", opts.Depth))
+	}
+	if opts.SingleBranch {
+		args = append(args, "--single-branch")
+		if opts.Ref != "" {
+			args = append(args, "--branch", opts.Ref)
+		}
+	}
+	args = append(args, cloneSpec, dir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return run(opts.Timeout, dir, "git", args...)
+}
+
+// shallowFetchSHA shallow-fetches a single commit SHA that isn't
+// necessarily at the tip of any branch, via "--filter=blob:none" plus
+// a direct "fetch <sha>", since "git clone --depth" can't target an
+// arbitrary commit.
+func shallowFetchSHA(dir, cloneSpec string, opts CloneOptions) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := run(opts.Timeout, dir, "git", "init", "-q"); err != nil {
+		return err
+	}
+	if err := run(opts.Timeout, dir, "git", "remote", "add", "origin", cloneSpec); err != nil {
+		return err
+	}
+	fetchArgs := []string{"fetch", "--filter=blob:none", fmt.Sprintf("--depth=%d", opts.Depth), "origin", opts.Ref}
+	if err := run(opts.Timeout, dir, "git", fetchArgs...); err != nil {
+		return err
+	}
+	return run(opts.Timeout, dir, "git", "checkout", "FETCH_HEAD")
+}
+
+// isLocalCloneSpec reports whether cloneSpec names a repo on the local
+// filesystem rather than a remote one.
+func isLocalCloneSpec(cloneSpec string) bool {
+	return strings.HasPrefix(cloneSpec, fileScheme) || filepath.IsAbs(cloneSpec)
+}
+
+// archiveClone populates dir with the contents of subdir at ref from
+// the local repo at cloneSpec, via "git archive --remote | tar x",
+// without fetching the rest of the repo's history or tree. Both
+// commands are bounded by timeout (or defaultTimeout, if timeout is
+// zero or negative).
+func archiveClone(dir, cloneSpec, ref, subdir string, timeout time.Duration) error {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	remote := strings.TrimPrefix(cloneSpec, fileScheme)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	archiveArgs := []string{"archive", "--remote=" + remote, ref}
+	if subdir != "" {
+		archiveArgs = append(archiveArgs, subdir)
+	}
+	archiveCmd := exec.CommandContext(ctx, "git", archiveArgs...)
+	tarCmd := exec.CommandContext(ctx, "tar", "x", "-C", dir)
+
+	pipe, err := archiveCmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	tarCmd.Stdin = pipe
+	var archiveErr bytes.Buffer
+	archiveCmd.Stderr = &archiveErr
+
+	if err := tarCmd.Start(); err != nil {
+		return err
+	}
+	if err := archiveCmd.Run(); err != nil {
+		return fmt.Errorf("git archive --remote=%s: %w: %s", remote, err, archiveErr.String())
+	}
+	return tarCmd.Wait()
+}
+
+func (gitBackend) Checkout(dir, ref string, timeout time.Duration) error {
+	return run(timeout, dir, "git", "checkout", ref)
+}
+
+func (gitBackend) Submodules(dir string, timeout time.Duration) error {
+	return run(timeout, dir, "git", "submodule", "update", "--init", "--recursive")
+}
+
+type hgBackend struct{}
+
+func (hgBackend) Clone(dir, cloneSpec string, opts CloneOptions) error {
+	if opts.Ref == "" {
+		return run(opts.Timeout, dir, "hg", "clone", cloneSpec, dir)
+	}
+	return run(opts.Timeout, dir, "hg", "clone", "-u", opts.Ref, cloneSpec, dir)
+}
+
+func (hgBackend) Checkout(dir, ref string, timeout time.Duration) error {
+	return run(timeout, dir, "hg", "update", ref)
+}
+
+func (hgBackend) Submodules(dir string, timeout time.Duration) error {
+	// Mercurial subrepositories are fetched as part of clone/update.
+	return nil
+}
+
+type svnBackend struct{}
+
+func (svnBackend) Clone(dir, cloneSpec string, opts CloneOptions) error {
+	if opts.Ref == "" {
+		return run(opts.Timeout, dir, "svn", "checkout", cloneSpec, dir)
+	}
+	return run(opts.Timeout, dir, "svn", "checkout", "-r", opts.Ref, cloneSpec, dir)
+}
+
+func (svnBackend) Checkout(dir, ref string, timeout time.Duration) error {
+	return run(timeout, dir, "svn", "update", "-r", ref)
+}
+
+func (svnBackend) Submodules(dir string, timeout time.Duration) error {
+	// Subversion has no submodule concept of its own.
+	return nil
+}
+
+type bzrBackend struct{}
+
+func (bzrBackend) Clone(dir, cloneSpec string, opts CloneOptions) error {
+	if opts.Ref == "" {
+		return run(opts.Timeout, dir, "bzr", "branch", cloneSpec, dir)
+	}
+	return run(opts.Timeout, dir, "bzr", "branch", "-r", opts.Ref, cloneSpec, dir)
+}
+
+func (bzrBackend) Checkout(dir, ref string, timeout time.Duration) error {
+	return run(timeout, dir, "bzr", "update", "-r", ref)
+}
+
+func (bzrBackend) Submodules(dir string, timeout time.Duration) error {
+	return nil
+}
+
+type fossilBackend struct{}
+
+func (fossilBackend) Clone(dir, cloneSpec string, opts CloneOptions) error {
+	repo := dir + ".fossil"
+	if err := run(opts.Timeout, dir, "fossil", "clone", cloneSpec, repo); err != nil {
+		return err
+	}
+	return run(opts.Timeout, dir, "fossil", "open", repo)
+}
+
+func (fossilBackend) Checkout(dir, ref string, timeout time.Duration) error {
+	return run(timeout, dir, "fossil", "update", ref)
+}
+
+func (fossilBackend) Submodules(dir string, timeout time.Duration) error {
+	return nil
+}
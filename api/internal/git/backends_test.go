@@ -0,0 +1,168 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestGitRepo creates a temp git repo on disk with a couple of
+// files under a subdirectory, and returns its path.
+func newTestGitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	run("init", "-q", "-b", "main")
+	if err := os.MkdirAll(filepath.Join(dir, "overlays", "prod"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "overlays", "prod", "kustomization.yaml"), []byte("resources: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("hi\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", "-A")
+	run("commit", "-q", "-m", "initial")
+	return dir
+}
+
+// listTree returns every regular file path under dir, relative to dir.
+func listTree(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".git") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestGitBackendClone_ArchiveAndCloneAgree(t *testing.T) {
+	repo := newTestGitRepo(t)
+
+	archiveDir := t.TempDir()
+	if err := (gitBackend{}).Clone(archiveDir, repo, CloneOptions{Ref: "HEAD", Subdir: "overlays/prod"}); err != nil {
+		t.Fatalf("archive clone: %v", err)
+	}
+
+	// A Subdir pathspec that doesn't exist at Ref makes the "git
+	// archive" fast-path fail, so this exercises Clone()'s real
+	// archive-fails-so-fall-back-to-clone branch rather than just
+	// calling gitClone directly: isLocalCloneSpec still matches
+	// "file://"+repo, but the archive itself errors out, and Clone()
+	// retries with a plain "git clone" that fetches the whole repo
+	// (gitClone ignores Subdir) before anything looks at "overlays/prod".
+	fallbackDir := filepath.Join(t.TempDir(), "fallback")
+	if err := (gitBackend{}).Clone(fallbackDir, "file://"+repo, CloneOptions{Ref: "HEAD", Subdir: "does-not-exist"}); err != nil {
+		t.Fatalf("fallback clone via Clone(): %v", err)
+	}
+
+	archiveFiles := listTree(t, filepath.Join(archiveDir, "overlays", "prod"))
+	fallbackFiles := listTree(t, filepath.Join(fallbackDir, "overlays", "prod"))
+	if len(archiveFiles) == 0 || len(fallbackFiles) == 0 {
+		t.Fatalf("expected files in both trees, got archive=%v fallback=%v", archiveFiles, fallbackFiles)
+	}
+	if !equalStrings(archiveFiles, fallbackFiles) {
+		t.Errorf("archive and fallback-clone trees differ: archive=%v fallback=%v", archiveFiles, fallbackFiles)
+	}
+}
+
+// TestGitClone_RespectsOptsTimeout guards against CloneOptions.Timeout
+// being threaded in but ignored in favor of the hardcoded
+// defaultTimeout: a 1ns timeout must make the clone fail with a
+// deadline error rather than quietly running to completion.
+func TestGitClone_RespectsOptsTimeout(t *testing.T) {
+	repo := newTestGitRepo(t)
+
+	dir := filepath.Join(t.TempDir(), "clone")
+	err := gitClone(dir, repo, CloneOptions{Ref: "main", Timeout: time.Nanosecond})
+	if err == nil {
+		t.Fatal("expected a 1ns timeout to fail the clone")
+	}
+	if !strings.Contains(err.Error(), "context deadline exceeded") && !strings.Contains(err.Error(), "signal: killed") {
+		t.Errorf("expected a deadline-exceeded error, got: %v", err)
+	}
+}
+
+func TestGitClone_ShallowAndSingleBranch(t *testing.T) {
+	repo := newTestGitRepo(t)
+
+	dir := filepath.Join(t.TempDir(), "clone")
+	err := gitClone(dir, repo, CloneOptions{Ref: "main", Depth: 1, SingleBranch: true})
+	if err != nil {
+		t.Fatalf("shallow single-branch clone: %v", err)
+	}
+	files := listTree(t, filepath.Join(dir, "overlays", "prod"))
+	if len(files) == 0 {
+		t.Fatalf("expected files in shallow clone, got none")
+	}
+
+	out, lerr := exec.Command("git", "-C", dir, "log", "--oneline").CombinedOutput()
+	if lerr != nil {
+		t.Fatalf("git log: %v: %s", lerr, out)
+	}
+	if n := len(strings.TrimSpace(string(out))); n == 0 {
+		t.Fatalf("expected at least one commit in shallow clone")
+	}
+}
+
+func TestIsGitSHA(t *testing.T) {
+	cases := map[string]bool{
+		"main":                         false,
+		"v1.0.0":                       false,
+		"deadbee":                      true,
+		"deadbeefdeadbeefdeadbeefdead": true,
+		"not-a-sha":                    false,
+	}
+	for ref, want := range cases {
+		if got := isGitSHA(ref); got != want {
+			t.Errorf("isGitSHA(%q) = %v, want %v", ref, got, want)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
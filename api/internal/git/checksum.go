@@ -0,0 +1,103 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// hashAlgoPrefix tags the digest format, mirroring the "h1:" tag Go
+// uses for its module sum database. There's only one algorithm today,
+// but the tag leaves room to rev it without breaking existing pins.
+const hashAlgoPrefix = "h1:"
+
+// VerifyChecksum recomputes the checksum of x.AbsPath() and compares
+// it against x.ExpectedHash, returning nil if there's nothing to check
+// or the checksums agree. On a mismatch the error lists the files that
+// make up the actual digest, to make a bad pin easy to diagnose.
+func (x *RepoSpec) VerifyChecksum() error {
+	if x.ExpectedHash == "" {
+		return nil
+	}
+	actual, files, err := hashDir(x.AbsPath())
+	if err != nil {
+		return fmt.Errorf("unable to checksum %s: %w", x.AbsPath(), err)
+	}
+	if actual == x.ExpectedHash {
+		return nil
+	}
+	return fmt.Errorf(
+		"checksum mismatch for %s: expected %s, got %s over %d files:\n%s",
+		x.AbsPath(), x.ExpectedHash, actual, len(files), strings.Join(files, "\n"))
+}
+
+// ComputeChecksum hashes every regular file under dir the same way
+// VerifyChecksum does, returning a digest suitable for a "?hash="
+// query parameter.
+func ComputeChecksum(dir string) (string, error) {
+	sum, _, err := hashDir(dir)
+	return sum, err
+}
+
+// hashDir implements a dirhash.HashDir-compatible digest: every
+// regular file under dir is hashed with SHA-256, then the lines
+// "<hex sha256>  <relpath>\n", sorted by relpath, are themselves
+// hashed with SHA-256 to produce the top-level digest.
+func hashDir(dir string) (sum string, lines []string, err error) {
+	var relPaths []string
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		relPaths = append(relPaths, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	sort.Strings(relPaths)
+
+	lines = make([]string, 0, len(relPaths))
+	for _, rel := range relPaths {
+		h, err := hashFile(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return "", nil, err
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s\n", h, rel))
+	}
+
+	h := sha256.New()
+	for _, line := range lines {
+		io.WriteString(h, line)
+	}
+	return hashAlgoPrefix + base64.StdEncoding.EncodeToString(h.Sum(nil)), lines, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
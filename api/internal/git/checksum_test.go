@@ -0,0 +1,84 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func newTestDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.yaml"), []byte("a: 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "b.yaml"), []byte("b: 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestComputeChecksum(t *testing.T) {
+	dir := newTestDir(t)
+
+	sum, err := ComputeChecksum(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(sum, hashAlgoPrefix) {
+		t.Errorf("expected checksum to start with %q, got %q", hashAlgoPrefix, sum)
+	}
+
+	// Hashing the same tree again should be deterministic.
+	sum2, err := ComputeChecksum(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sum != sum2 {
+		t.Errorf("expected a stable checksum, got %q then %q", sum, sum2)
+	}
+
+	// Changing a file's contents must change the checksum.
+	if err := os.WriteFile(filepath.Join(dir, "a.yaml"), []byte("a: 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum3, err := ComputeChecksum(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sum3 == sum {
+		t.Errorf("expected checksum to change after editing a file, still got %q", sum3)
+	}
+}
+
+func TestRepoSpec_VerifyChecksum(t *testing.T) {
+	dir := newTestDir(t)
+	sum, err := ComputeChecksum(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rs := &RepoSpec{Dir: filesys.ConfirmedDir(dir), ExpectedHash: sum}
+	if err := rs.VerifyChecksum(); err != nil {
+		t.Errorf("expected matching checksum to verify cleanly: %v", err)
+	}
+
+	rs.ExpectedHash = hashAlgoPrefix + "deadbeef"
+	if err := rs.VerifyChecksum(); err == nil {
+		t.Error("expected a mismatched checksum to fail verification")
+	}
+
+	rs.ExpectedHash = ""
+	if err := rs.VerifyChecksum(); err != nil {
+		t.Errorf("expected no pin to verify cleanly: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnableDynamicDiscovery turns on go-import meta tag discovery for
+// every RepoSpec, without requiring a "?discover=true" query
+// parameter on each individual url. It's off by default because it
+// makes an outbound network call for any base that doesn't match one
+// of the well-known hosts.
+var EnableDynamicDiscovery = false
+
+// discoverTimeout bounds the "?go-get=1" HTTP round trip.
+const discoverTimeout = 10 * time.Second
+
+// reGoImport matches a <meta name="go-import" content="prefix vcs repoRoot"> tag.
+var reGoImport = regexp.MustCompile(
+	`(?i)<meta\s+name=["']go-import["']\s+content=["']([^"']+)["']\s*/?>`)
+
+// discoverCache memoizes go-import resolutions by the import path
+// prefix they were discovered under, for the lifetime of the process.
+// Keying by prefix (rather than by host) lets a single vanity domain
+// that fronts more than one repo cache each of its prefixes
+// independently.
+var discoverCache sync.Map // map[string]goImport
+
+type goImport struct {
+	prefix   string
+	vcs      string
+	repoRoot string
+}
+
+// discoverRemote resolves importPath (a host/path with no recognized
+// scheme or well-known host) the way "go get" resolves vanity import
+// paths: it fetches "https://host/path?go-get=1", looks for a
+// <meta name="go-import"> tag whose prefix is a prefix of importPath,
+// and rewrites the result into a host/orgRepo/path triple.
+func discoverRemote(importPath string) (host, orgRepo, path string, ok bool) {
+	slash := strings.Index(importPath, "/")
+	if slash < 0 {
+		return "", "", "", false
+	}
+	webHost := importPath[:slash]
+
+	gi, ok := lookupGoImport(webHost, importPath)
+	if !ok {
+		return "", "", "", false
+	}
+
+	rsHost, rest := parseHostSpec(gi.repoRoot)
+	if rsHost == "" {
+		return "", "", "", false
+	}
+	repoOrgRepo, _ := splitOrgRepoPath(rest)
+
+	path = strings.TrimPrefix(importPath[len(gi.prefix):], "/")
+	return rsHost, repoOrgRepo, path, true
+}
+
+// lookupGoImport finds the go-import tag, if any, whose prefix
+// importPath falls under, caching by the prefix that was discovered.
+// A cache miss (no cached prefix covers importPath, which includes the
+// case of a second, different prefix under an already-seen host) always
+// triggers a fresh fetch.
+func lookupGoImport(webHost, importPath string) (goImport, bool) {
+	if gi, found := cachedGoImport(importPath); found {
+		return gi, true
+	}
+
+	gi, ok := fetchGoImport(webHost, importPath)
+	if ok {
+		discoverCache.Store(gi.prefix, gi)
+	}
+	return gi, ok
+}
+
+// cachedGoImport returns the longest cached prefix that importPath
+// falls under, if any.
+func cachedGoImport(importPath string) (goImport, bool) {
+	var best goImport
+	found := false
+	discoverCache.Range(func(key, value interface{}) bool {
+		prefix := key.(string)
+		if strings.HasPrefix(importPath, prefix) && len(prefix) > len(best.prefix) {
+			best = value.(goImport)
+			found = true
+		}
+		return true
+	})
+	return best, found
+}
+
+// fetchGoImport performs the "?go-get=1" HTTP GET and parses out the
+// go-import meta tag that best matches importPath.
+func fetchGoImport(webHost, importPath string) (goImport, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), discoverTimeout)
+	defer cancel()
+
+	url := "https://" + webHost + "/" + strings.TrimPrefix(importPath[len(webHost):], "/") + "?go-get=1"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return goImport{}, false
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return goImport{}, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return goImport{}, false
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return goImport{}, false
+	}
+
+	var best goImport
+	for _, m := range reGoImport.FindAllStringSubmatch(string(body), -1) {
+		fields := strings.Fields(m[1])
+		if len(fields) != 3 {
+			continue
+		}
+		prefix, vcs, repoRoot := fields[0], fields[1], fields[2]
+		if !strings.HasPrefix(importPath, prefix) {
+			continue
+		}
+		if len(prefix) > len(best.prefix) {
+			best = goImport{prefix: prefix, vcs: vcs, repoRoot: repoRoot}
+		}
+	}
+	if best.prefix == "" {
+		return goImport{}, false
+	}
+	return best, true
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// withDiscoverServer spins up a TLS test server that answers "?go-get=1"
+// requests with a go-import meta tag for each given relative prefix
+// (host-qualified the way a real go-import tag is), points
+// http.DefaultClient at it for the duration of the test, and restores
+// both http.DefaultClient and discoverCache on cleanup.
+func withDiscoverServer(t *testing.T, repoRootByPrefix map[string]string) (webHost string, hits *int) {
+	t.Helper()
+	hits = new(int)
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*hits++
+		for prefix, repoRoot := range repoRootByPrefix {
+			if strings.HasPrefix(strings.TrimPrefix(r.URL.Path, "/"), prefix) {
+				fmt.Fprintf(w, `<html><head><meta name="go-import" content="%s/%s git %s"></head></html>`,
+					r.Host, prefix, repoRoot)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(ts.Close)
+
+	origClient := http.DefaultClient
+	http.DefaultClient = ts.Client()
+	t.Cleanup(func() { http.DefaultClient = origClient })
+	t.Cleanup(func() { discoverCache = sync.Map{} })
+
+	return strings.TrimPrefix(ts.URL, "https://"), hits
+}
+
+// TestDiscoverRemote_FetchAndCache exercises the real "?go-get=1" HTTP
+// fetch and asserts a second lookup under the same prefix is served
+// from the cache rather than hitting the server again.
+func TestDiscoverRemote_FetchAndCache(t *testing.T) {
+	webHost, hits := withDiscoverServer(t, map[string]string{
+		"team/config": "https://example.com/team/config.git",
+	})
+
+	host, orgRepo, path, ok := discoverRemote(webHost + "/team/config/overlays/prod")
+	if !ok {
+		t.Fatal("expected discovery to succeed")
+	}
+	if host != "https://example.com/" || orgRepo != "team/config.git" || path != "overlays/prod" {
+		t.Errorf("unexpected discovery result: host=%s orgRepo=%s path=%s", host, orgRepo, path)
+	}
+	if *hits != 1 {
+		t.Fatalf("expected 1 fetch, got %d", *hits)
+	}
+
+	if _, _, _, ok := discoverRemote(webHost + "/team/config/overlays/staging"); !ok {
+		t.Fatal("expected cached discovery to succeed")
+	}
+	if *hits != 1 {
+		t.Errorf("expected second lookup to hit the cache, but it fetched again (hits=%d)", *hits)
+	}
+}
+
+// TestDiscoverRemote_DistinctPrefixesSameHost covers the bug where a
+// single host-keyed cache slot would permanently shadow every prefix
+// but the first one discovered under it: a vanity domain fronting two
+// different repos must resolve (and cache) both independently.
+func TestDiscoverRemote_DistinctPrefixesSameHost(t *testing.T) {
+	webHost, hits := withDiscoverServer(t, map[string]string{
+		"teamA/config": "https://example.com/teamA/config.git",
+		"teamB/config": "https://example.com/teamB/config.git",
+	})
+
+	_, orgRepoA, _, ok := discoverRemote(webHost + "/teamA/config/overlays/prod")
+	if !ok {
+		t.Fatal("expected discovery of the first prefix to succeed")
+	}
+	if orgRepoA != "teamA/config.git" {
+		t.Errorf("expected teamA/config.git, got %s", orgRepoA)
+	}
+	if *hits != 1 {
+		t.Fatalf("expected 1 fetch after the first prefix, got %d", *hits)
+	}
+
+	_, orgRepoB, _, ok := discoverRemote(webHost + "/teamB/config/overlays/prod")
+	if !ok {
+		t.Fatal("expected discovery of the second prefix under the same host to succeed")
+	}
+	if orgRepoB != "teamB/config.git" {
+		t.Errorf("expected teamB/config.git, got %s", orgRepoB)
+	}
+	if *hits != 2 {
+		t.Errorf("expected the second, different prefix to be fetched rather than served from the first prefix's cache slot (hits=%d)", *hits)
+	}
+}
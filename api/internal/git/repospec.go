@@ -0,0 +1,484 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	refQuery = "?ref="
+
+	gitSuffix = ".git"
+
+	defaultSubmodules = true
+	defaultTimeout    = 27 * time.Second
+)
+
+// Used as a temporary non-empty occupant of the cloneDir
+// field, as something to check against.
+const notCloned = filesys.ConfirmedDir("")
+
+// VCS identifies the kind of version control system that backs a RepoSpec.
+type VCS int
+
+const (
+	// Git is the default, and by far the most common, backend.
+	Git VCS = iota
+	Hg
+	SVN
+	Bzr
+	Fossil
+)
+
+// String renders the VCS kind the way it appears in a forcing prefix,
+// e.g. "hg::example.com/repo".
+func (v VCS) String() string {
+	switch v {
+	case Hg:
+		return "hg"
+	case SVN:
+		return "svn"
+	case Bzr:
+		return "bzr"
+	case Fossil:
+		return "fossil"
+	default:
+		return "git"
+	}
+}
+
+// vcsSuffixes maps the checkout-identifying file suffix used by each
+// backend (when present in the URL) to the VCS it implies.
+var vcsSuffixes = []struct {
+	suffix string
+	vcs    VCS
+}{
+	{".git", Git},
+	{".hg", Hg},
+	{".bzr", Bzr},
+}
+
+// vcsForcePrefixes maps a "<vcs>::" forcing prefix, analogous to the
+// long-standing "git::" prefix, to the VCS it selects.
+var vcsForcePrefixes = []struct {
+	prefix string
+	vcs    VCS
+}{
+	{"git::", Git},
+	{"hg::", Hg},
+	{"svn::", SVN},
+	{"bzr::", Bzr},
+	{"fossil::", Fossil},
+}
+
+// RepoSpec specifies a remote repository and a branch and path therein.
+type RepoSpec struct {
+	// Raw, original spec, used to look for cycles.
+	raw string
+
+	// VCS is the version control system that owns Host/OrgRepo.
+	VCS VCS
+
+	// Host, e.g. https://github.com/
+	Host string
+
+	// orgRepo name (organization/repoName), e.g. kubernetes-sigs/kustomize
+	OrgRepo string
+
+	// Dir is where the repo is cloned to.
+	Dir filesys.ConfirmedDir
+
+	// Relative path in the repo, e.g. api/krusty
+	Path string
+
+	// Branch, tag or commit reference.
+	Ref string
+
+	// Submodules indicates whether or not to clone submodules.
+	Submodules bool
+
+	// Timeout is the maximum duration allowed for execing VCS commands.
+	Timeout time.Duration
+
+	// ExpectedHash is the optional "h1:" checksum pin from a "?hash="
+	// query parameter, checked by VerifyChecksum after checkout.
+	ExpectedHash string
+
+	// Depth is the optional shallow-clone depth from a "?depth="
+	// query parameter. Zero means a full clone.
+	Depth int
+
+	// SingleBranch indicates whether to pass "--single-branch" (and
+	// "--branch Ref") to git clone, from a "?singleBranch=" query
+	// parameter.
+	SingleBranch bool
+
+	// LFS indicates whether to run "git lfs pull" at the end of a git
+	// clone, before Checkout runs, from a "?lfs=" query parameter.
+	LFS bool
+}
+
+// CloneSpec returns a string suitable for "git clone {spec}" (or the
+// equivalent invocation for other backends).
+func (x *RepoSpec) CloneSpec() string {
+	if x.Host == localHost || isAzureHost(x.Host) || isAWSHost(x.Host) || strings.Contains(x.OrgRepo, "_git/") {
+		return x.Host + x.OrgRepo
+	}
+	if suffix := x.vcsSuffix(); suffix != "" && strings.HasSuffix(x.OrgRepo, suffix) {
+		return x.Host + x.OrgRepo
+	}
+	return x.Host + x.OrgRepo + x.vcsSuffix()
+}
+
+// vcsSuffix is the file extension this RepoSpec's backend uses to mark
+// a clone URL. Only Git conventionally suffixes its clone urls this
+// way; the rest are addressed by bare host+orgRepo.
+func (x *RepoSpec) vcsSuffix() string {
+	if x.VCS == Git {
+		return gitSuffix
+	}
+	return ""
+}
+
+func (x *RepoSpec) CloneDir() filesys.ConfirmedDir {
+	return x.Dir
+}
+
+func (x *RepoSpec) Raw() string {
+	return x.raw
+}
+
+func (x *RepoSpec) AbsPath() string {
+	return filepath.Join(string(x.Dir), x.Path)
+}
+
+func (x *RepoSpec) Cleaner(fSys filesys.FileSystem) func() error {
+	return func() error { return fSys.RemoveAll(string(x.Dir)) }
+}
+
+// CloneOptions bundles x's clone-tuning settings into the form a
+// Backend.Clone call expects.
+func (x *RepoSpec) CloneOptions() CloneOptions {
+	return CloneOptions{
+		Ref:          x.Ref,
+		Subdir:       x.Path,
+		Depth:        x.Depth,
+		SingleBranch: x.SingleBranch,
+		LFS:          x.LFS,
+		Timeout:      x.Timeout,
+	}
+}
+
+// NewRepoSpecFromUrl parses git-like (or other VCS) urls.
+// From strings like git@github.com:org/repo.git or
+// https://github.com/org/repo.git, and the hg/svn/bzr/fossil
+// equivalents.
+func NewRepoSpecFromUrl(raw string) (*RepoSpec, error) {
+	vcs, n := peelVCSForcePrefix(raw)
+	if isLocalSpec(n) {
+		return newLocalRepoSpec(raw, vcs, n)
+	}
+	host, rest := parseHostSpec(n)
+	bareURI, q := peelQuery(rest)
+	orgRepo, path := splitOrgRepoPath(bareURI)
+	if importPath, ok := vanityImportPathCandidate(n); ok && (EnableDynamicDiscovery || q.discover) {
+		if dHost, dOrgRepo, dPath, ok := discoverRemote(importPath); ok {
+			host, orgRepo, path = dHost, dOrgRepo, dPath
+		}
+	}
+	if vcs == Git {
+		vcs = vcsFromSuffix(orgRepo)
+	}
+	if orgRepo == "" {
+		return nil, fmt.Errorf("url lacks orgRepo: %s", n)
+	}
+	if host == "" {
+		return nil, fmt.Errorf("url lacks host: %s", n)
+	}
+	return &RepoSpec{
+		raw:          raw,
+		VCS:          vcs,
+		Host:         host,
+		OrgRepo:      orgRepo,
+		Dir:          notCloned,
+		Path:         path,
+		Ref:          q.ref,
+		Submodules:   q.submodules,
+		Timeout:      q.timeout,
+		ExpectedHash: q.hash,
+		Depth:        q.depth,
+		SingleBranch: q.singleBranch,
+		LFS:          q.lfs,
+	}, nil
+}
+
+// fileScheme is the explicit local-repo url prefix, e.g.
+// "file:///abs/path/to/repo//overlays/prod?ref=v1".
+const fileScheme = "file://"
+
+// localHost is the synthetic Host value used for a RepoSpec that
+// addresses a repo on the local filesystem rather than a remote one.
+const localHost = fileScheme
+
+// isLocalSpec reports whether n addresses a repo on the local
+// filesystem, either via an explicit "file://" prefix or a bare
+// absolute path.
+func isLocalSpec(n string) bool {
+	return len(n) >= len(fileScheme) && strings.EqualFold(n[:len(fileScheme)], fileScheme) ||
+		filepath.IsAbs(n)
+}
+
+// newLocalRepoSpec builds a RepoSpec for a local filesystem repo. The
+// orgRepo here is the path to the repo itself; an explicit "//" still
+// separates it from an in-repo subdirectory.
+func newLocalRepoSpec(raw string, vcs VCS, n string) (*RepoSpec, error) {
+	if len(n) >= len(fileScheme) && strings.EqualFold(n[:len(fileScheme)], fileScheme) {
+		n = n[len(fileScheme):]
+	}
+	bareURI, q := peelQuery(n)
+	orgRepo, path := bareURI, ""
+	if i := strings.Index(bareURI, "//"); i >= 0 {
+		orgRepo, path = bareURI[:i], bareURI[i+2:]
+	}
+	if orgRepo == "" {
+		return nil, fmt.Errorf("url lacks orgRepo: %s", raw)
+	}
+	if vcs == Git {
+		vcs = vcsFromSuffix(orgRepo)
+	}
+	return &RepoSpec{
+		raw:          raw,
+		VCS:          vcs,
+		Host:         localHost,
+		OrgRepo:      orgRepo,
+		Dir:          notCloned,
+		Path:         path,
+		Ref:          q.ref,
+		Submodules:   q.submodules,
+		Timeout:      q.timeout,
+		ExpectedHash: q.hash,
+		Depth:        q.depth,
+		SingleBranch: q.singleBranch,
+		LFS:          q.lfs,
+	}, nil
+}
+
+// peelVCSForcePrefix strips a leading "<vcs>::" prefix such as "hg::"
+// and returns the VCS it selects (Git if none was present).
+func peelVCSForcePrefix(n string) (VCS, string) {
+	for _, p := range vcsForcePrefixes {
+		if len(n) >= len(p.prefix) && strings.EqualFold(n[:len(p.prefix)], p.prefix) {
+			return p.vcs, n[len(p.prefix):]
+		}
+	}
+	return Git, n
+}
+
+// vcsFromSuffix infers a VCS from a recognized repo-identifying file
+// suffix (e.g. ".hg") anchored to one of orgRepo's "/"-separated
+// segments, defaulting to Git. Anchoring to a whole segment, the same
+// way splitOrgRepoPath does, keeps an org or repo name that merely
+// contains a suffix as a substring (e.g. "my.hgstuff") from being
+// misclassified.
+func vcsFromSuffix(orgRepo string) VCS {
+	for _, seg := range strings.Split(orgRepo, "/") {
+		for _, s := range vcsSuffixes {
+			if strings.HasSuffix(seg, s.suffix) {
+				return s.vcs
+			}
+		}
+	}
+	return Git
+}
+
+var (
+	reGH            = regexp.MustCompile(`(?i)^gh:(.*)$`)
+	reSCP           = regexp.MustCompile(`^(\w[\w.+-]*@[-\w.]+)(:[0-9]+)?([:/])(.*)$`)
+	reScheme        = regexp.MustCompile(`(?i)^(https?|ssh|git)://([^/]+)/?(.*)$`)
+	reBareKnownHost = regexp.MustCompile(`(?i)^(github\.com|gitlab\.com|bitbucket\.org)([:/])(.*)$`)
+)
+
+// knownHosts are the well-known hosting providers that kustomize
+// recognizes without a scheme and always reaches over https.
+var knownHosts = []string{"github.com", "gitlab.com", "bitbucket.org"}
+
+func isKnownHost(h string) bool {
+	h = strings.ToLower(h)
+	for _, k := range knownHosts {
+		if h == k {
+			return true
+		}
+	}
+	return false
+}
+
+// vanityImportPathCandidate reports whether n addresses a host that
+// kustomize doesn't already recognize as well-known, and if so returns
+// the bare "host/path" substring (scheme and query stripped) that
+// discoverRemote resolves, the way "go get" decides whether an import
+// path needs go-import discovery. This fires for both schemeless paths
+// (example.com/org/repo) and explicit http or https ones
+// (https://example.com/org/repo): cmd/go performs discovery
+// independent of an explicit scheme. scp-style, "gh:"-shorthand,
+// ssh:// and git:// urls and already-known hosts are never candidates.
+func vanityImportPathCandidate(n string) (importPath string, ok bool) {
+	if i := strings.Index(n, "?"); i >= 0 {
+		n = n[:i]
+	}
+	if reGH.MatchString(n) || reSCP.MatchString(n) || reBareKnownHost.MatchString(n) {
+		return "", false
+	}
+	if m := reScheme.FindStringSubmatch(n); m != nil {
+		scheme := strings.ToLower(m[1])
+		if (scheme != "http" && scheme != "https") || isKnownHost(m[2]) {
+			return "", false
+		}
+		return m[2] + "/" + m[3], true
+	}
+	return n, true
+}
+
+// parseHostSpec pulls a normalized host (with trailing separator) off
+// the front of n, returning the host and whatever remains.
+func parseHostSpec(n string) (host, rest string) {
+	if m := reGH.FindStringSubmatch(n); m != nil {
+		return "gh:", m[1]
+	}
+	if m := reSCP.FindStringSubmatch(n); m != nil {
+		userHost, port, sep, rest := m[1], m[2], m[3], m[4]
+		if port != "" {
+			return userHost + port + sep, rest
+		}
+		return userHost + ":", rest
+	}
+	if m := reScheme.FindStringSubmatch(n); m != nil {
+		scheme, hostport, rest := strings.ToLower(m[1]), m[2], m[3]
+		if isKnownHost(hostport) {
+			hostport = strings.ToLower(hostport)
+			if scheme == "http" {
+				scheme = "https"
+			}
+		}
+		return scheme + "://" + hostport + "/", rest
+	}
+	if m := reBareKnownHost.FindStringSubmatch(n); m != nil {
+		return "https://" + strings.ToLower(m[1]) + "/", m[3]
+	}
+	return "", n
+}
+
+// splitOrgRepoPath divides the portion of a url following the host into
+// an orgRepo (the part that gets cloned) and an in-repo path.
+func splitOrgRepoPath(n string) (orgRepo, path string) {
+	if i := strings.Index(n, "//"); i >= 0 {
+		return n[:i], n[i+2:]
+	}
+	trimmed := strings.Trim(n, "/")
+	var segs []string
+	if trimmed != "" {
+		segs = strings.Split(trimmed, "/")
+	}
+	for i, s := range segs {
+		if s == "_git" && i+1 < len(segs) {
+			return strings.Join(segs[:i+2], "/"), strings.Join(segs[i+2:], "/")
+		}
+	}
+	for i, s := range segs {
+		if strings.HasSuffix(s, gitSuffix) || strings.HasSuffix(s, ".hg") || strings.HasSuffix(s, ".bzr") {
+			return strings.Join(segs[:i+1], "/"), strings.Join(segs[i+1:], "/")
+		}
+	}
+	if len(segs) < 2 {
+		return "", strings.Join(segs, "/")
+	}
+	return strings.Join(segs[:2], "/"), strings.Join(segs[2:], "/")
+}
+
+// queryParams bundles everything peelQuery pulls out of a url's query
+// string in one parse.
+type queryParams struct {
+	ref          string
+	timeout      time.Duration
+	submodules   bool
+	discover     bool
+	hash         string
+	depth        int
+	singleBranch bool
+	lfs          bool
+}
+
+// peelQuery splits off the "?ref=..." (and friends) query parameters,
+// returning the bare path plus every clone-tuning setting kustomize
+// recognizes in one parse of the query string.
+func peelQuery(n string) (path string, q queryParams) {
+	values := url.Values{}
+	path = n
+	if i := strings.Index(n, "?"); i >= 0 {
+		path = n[:i]
+		if v, err := url.ParseQuery(n[i+1:]); err == nil {
+			values = v
+		}
+	}
+	q.ref = values.Get("ref")
+	if q.ref == "" {
+		q.ref = values.Get("version")
+	}
+	q.timeout = parseTimeout(values.Get("timeout"))
+	q.submodules = parseSubmodules(values.Get("submodules"))
+	q.discover, _ = strconv.ParseBool(values.Get("discover"))
+	q.hash = values.Get("hash")
+	if depth, err := strconv.Atoi(values.Get("depth")); err == nil && depth > 0 {
+		q.depth = depth
+	}
+	q.singleBranch, _ = strconv.ParseBool(values.Get("singleBranch"))
+	q.lfs, _ = strconv.ParseBool(values.Get("lfs"))
+	return path, q
+}
+
+func parseTimeout(s string) time.Duration {
+	if s == "" {
+		return defaultTimeout
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if n <= 0 {
+			return defaultTimeout
+		}
+		return time.Duration(n) * time.Second
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return defaultTimeout
+	}
+	return d
+}
+
+func parseSubmodules(s string) bool {
+	if s == "" {
+		return defaultSubmodules
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return defaultSubmodules
+	}
+	return b
+}
+
+func isAzureHost(host string) bool {
+	return strings.Contains(host, "visualstudio.com") ||
+		strings.Contains(host, "dev.azure.com")
+}
+
+func isAWSHost(host string) bool {
+	return strings.Contains(host, "amazonaws.com") &&
+		strings.Contains(host, "git-codecommit")
+}
@@ -89,7 +89,7 @@ func TestNewRepoSpecFromUrl(t *testing.T) {
 }
 
 var badData = [][]string{
-	{"/tmp", "uri looks like abs path"},
+	{"file://", "url lacks orgRepo"},
 	{"iauhsdiuashduas", "url lacks orgRepo"},
 	{"htxxxtp://github.com/", "url lacks host"},
 	{"ssh://git.example.com", "url lacks orgRepo"},
@@ -181,6 +181,18 @@ func TestNewRepoSpecFromUrl_CloneSpecs(t *testing.T) {
 			absPath:   notCloned.String(),
 			ref:       "",
 		},
+		{
+			input:     "hg::https://example.com/someorg/somerepo//somedir?ref=v1.0.0",
+			cloneSpec: "https://example.com/someorg/somerepo",
+			absPath:   notCloned.Join("somedir"),
+			ref:       "v1.0.0",
+		},
+		{
+			input:     "svn::https://example.com/someorg/somerepo/somedir",
+			cloneSpec: "https://example.com/someorg/somerepo",
+			absPath:   notCloned.Join("somedir"),
+			ref:       "",
+		},
 	}
 	for _, testcase := range testcases {
 		rs, err := NewRepoSpecFromUrl(testcase.input)
@@ -202,6 +214,21 @@ func TestNewRepoSpecFromUrl_CloneSpecs(t *testing.T) {
 	}
 }
 
+// TestRepoSpec_CloneOptions_Timeout guards against Timeout being parsed
+// onto RepoSpec but dropped on the floor before it reaches a Backend.
+func TestRepoSpec_CloneOptions_Timeout(t *testing.T) {
+	rs, err := NewRepoSpecFromUrl("https://github.com/someorg/somerepo?timeout=90")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rs.Timeout != 90*time.Second {
+		t.Fatalf("expected RepoSpec.Timeout to be 90s, got %v", rs.Timeout)
+	}
+	if got := rs.CloneOptions().Timeout; got != 90*time.Second {
+		t.Errorf("expected CloneOptions().Timeout to be 90s, got %v", got)
+	}
+}
+
 func TestIsAzureHost(t *testing.T) {
 	testcases := []struct {
 		input  string
@@ -236,10 +263,15 @@ func TestPeelQuery(t *testing.T) {
 	testcases := []struct {
 		input string
 
-		path       string
-		ref        string
-		submodules bool
-		timeout    time.Duration
+		path         string
+		ref          string
+		submodules   bool
+		timeout      time.Duration
+		discover     bool
+		hash         string
+		depth        int
+		singleBranch bool
+		lfs          bool
 	}{
 		{
 			// All empty.
@@ -353,15 +385,145 @@ func TestPeelQuery(t *testing.T) {
 			submodules: false,
 			timeout:    61 * time.Second,
 		},
+		{
+			// discover defaults to false.
+			input:      "somerepos",
+			path:       "somerepos",
+			submodules: defaultSubmodules,
+			timeout:    defaultTimeout,
+			discover:   false,
+		},
+		{
+			input:      "somerepos?discover=true",
+			path:       "somerepos",
+			submodules: defaultSubmodules,
+			timeout:    defaultTimeout,
+			discover:   true,
+		},
+		{
+			// Malformed discover value uses default.
+			input:      "somerepos?discover=maybe",
+			path:       "somerepos",
+			submodules: defaultSubmodules,
+			timeout:    defaultTimeout,
+			discover:   false,
+		},
+		{
+			// hash defaults to empty.
+			input:      "somerepos",
+			path:       "somerepos",
+			submodules: defaultSubmodules,
+			timeout:    defaultTimeout,
+			hash:       "",
+		},
+		{
+			input:      "somerepos?hash=h1:deadbeef",
+			path:       "somerepos",
+			submodules: defaultSubmodules,
+			timeout:    defaultTimeout,
+			hash:       "h1:deadbeef",
+		},
+		{
+			// depth defaults to zero (a full clone).
+			input:      "somerepos",
+			path:       "somerepos",
+			submodules: defaultSubmodules,
+			timeout:    defaultTimeout,
+			depth:      0,
+		},
+		{
+			input:      "somerepos?depth=1",
+			path:       "somerepos",
+			submodules: defaultSubmodules,
+			timeout:    defaultTimeout,
+			depth:      1,
+		},
+		{
+			input:      "somerepos?depth=50",
+			path:       "somerepos",
+			submodules: defaultSubmodules,
+			timeout:    defaultTimeout,
+			depth:      50,
+		},
+		{
+			// Empty, malformed or negative depth values use the default.
+			input:      "somerepos?depth=",
+			path:       "somerepos",
+			submodules: defaultSubmodules,
+			timeout:    defaultTimeout,
+			depth:      0,
+		},
+		{
+			input:      "somerepos?depth=lots",
+			path:       "somerepos",
+			submodules: defaultSubmodules,
+			timeout:    defaultTimeout,
+			depth:      0,
+		},
+		{
+			input:      "somerepos?depth=-1",
+			path:       "somerepos",
+			submodules: defaultSubmodules,
+			timeout:    defaultTimeout,
+			depth:      0,
+		},
+		{
+			// singleBranch defaults to false.
+			input:        "somerepos",
+			path:         "somerepos",
+			submodules:   defaultSubmodules,
+			timeout:      defaultTimeout,
+			singleBranch: false,
+		},
+		{
+			input:        "somerepos?singleBranch=true",
+			path:         "somerepos",
+			submodules:   defaultSubmodules,
+			timeout:      defaultTimeout,
+			singleBranch: true,
+		},
+		{
+			// Malformed singleBranch value uses the default.
+			input:        "somerepos?singleBranch=nope",
+			path:         "somerepos",
+			submodules:   defaultSubmodules,
+			timeout:      defaultTimeout,
+			singleBranch: false,
+		},
+		{
+			// lfs defaults to false.
+			input:      "somerepos",
+			path:       "somerepos",
+			submodules: defaultSubmodules,
+			timeout:    defaultTimeout,
+			lfs:        false,
+		},
+		{
+			input:      "somerepos?lfs=true",
+			path:       "somerepos",
+			submodules: defaultSubmodules,
+			timeout:    defaultTimeout,
+			lfs:        true,
+		},
+		{
+			// Malformed lfs value uses the default.
+			input:      "somerepos?lfs=nope",
+			path:       "somerepos",
+			submodules: defaultSubmodules,
+			timeout:    defaultTimeout,
+			lfs:        false,
+		},
 	}
 
 	for _, testcase := range testcases {
-		path, ref, timeout, submodules := peelQuery(testcase.input)
-		if path != testcase.path || ref != testcase.ref || timeout != testcase.timeout || submodules != testcase.submodules {
-			t.Errorf("peelQuery: expected (%s, %s, %v, %v) got (%s, %s, %v, %v) on %s",
-				testcase.path, testcase.ref, testcase.timeout, testcase.submodules,
-				path, ref, timeout, submodules,
-				testcase.input)
+		path, q := peelQuery(testcase.input)
+		if path != testcase.path || q.ref != testcase.ref || q.timeout != testcase.timeout || q.submodules != testcase.submodules ||
+			q.discover != testcase.discover || q.hash != testcase.hash || q.depth != testcase.depth ||
+			q.singleBranch != testcase.singleBranch || q.lfs != testcase.lfs {
+			t.Errorf("peelQuery(%s): expected (%s, %s, %v, %v, %v, %s, %d, %v, %v) got (%s, %s, %v, %v, %v, %s, %d, %v, %v)",
+				testcase.input,
+				testcase.path, testcase.ref, testcase.timeout, testcase.submodules, testcase.discover, testcase.hash, testcase.depth, testcase.singleBranch, testcase.lfs,
+				path, q.ref, q.timeout, q.submodules, q.discover, q.hash, q.depth, q.singleBranch, q.lfs)
 		}
 	}
 }
@@ -395,3 +557,119 @@ func TestIsAWSHost(t *testing.T) {
 		}
 	}
 }
+
+func TestNewRepoSpecFromUrl_LocalSpecs(t *testing.T) {
+	testcases := []struct {
+		input     string
+		cloneSpec string
+		absPath   string
+		ref       string
+	}{
+		{
+			input:     "file:///abs/path/to/repo//overlays/prod?ref=v1",
+			cloneSpec: "file:///abs/path/to/repo",
+			absPath:   notCloned.Join("overlays/prod"),
+			ref:       "v1",
+		},
+		{
+			input:     "/tmp/somerepo",
+			cloneSpec: "file:///tmp/somerepo",
+			absPath:   notCloned.String(),
+			ref:       "",
+		},
+	}
+	for _, testcase := range testcases {
+		rs, err := NewRepoSpecFromUrl(testcase.input)
+		if err != nil {
+			t.Fatalf("Unexpected error on %s: %v", testcase.input, err)
+		}
+		if rs.CloneSpec() != testcase.cloneSpec {
+			t.Errorf("CloneSpec expected to be %v, but got %v on %s",
+				testcase.cloneSpec, rs.CloneSpec(), testcase.input)
+		}
+		if rs.AbsPath() != testcase.absPath {
+			t.Errorf("AbsPath expected to be %v, but got %v on %s",
+				testcase.absPath, rs.AbsPath(), testcase.input)
+		}
+		if rs.Ref != testcase.ref {
+			t.Errorf("ref expected to be %v, but got %v on %s",
+				testcase.ref, rs.Ref, testcase.input)
+		}
+	}
+}
+
+func TestNewRepoSpecFromUrl_DynamicDiscoveryOptIn(t *testing.T) {
+	// Without opting in, a vanity domain that isn't one of the
+	// well-known hosts simply fails to resolve a host, same as
+	// before discovery existed.
+	if _, err := NewRepoSpecFromUrl("example.com/team/config"); err == nil {
+		t.Error("expected error for an unrecognized host with discovery disabled")
+	}
+}
+
+// TestNewRepoSpecFromUrl_DynamicDiscoveryRewrite exercises discovery
+// through the public NewRepoSpecFromUrl entry point, rather than
+// calling discoverRemote directly, for both a schemeless vanity url and
+// an explicit-https one. The explicit-https case guards against a
+// regression where vanityImportPathCandidate failed to recognize such
+// urls as discovery candidates, so discoverRemote was never reached for
+// them.
+func TestNewRepoSpecFromUrl_DynamicDiscoveryRewrite(t *testing.T) {
+	webHost, _ := withDiscoverServer(t, map[string]string{
+		"team/config": "https://example.com/team/config.git",
+	})
+
+	origEnable := EnableDynamicDiscovery
+	EnableDynamicDiscovery = true
+	t.Cleanup(func() { EnableDynamicDiscovery = origEnable })
+
+	testcases := []struct {
+		name string
+		url  string
+	}{
+		{"schemeless", webHost + "/team/config/overlays/prod"},
+		{"explicit https", "https://" + webHost + "/team/config/overlays/prod"},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			rs, err := NewRepoSpecFromUrl(testcase.url)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if rs.Host != "https://example.com/" {
+				t.Errorf("expected Host rewritten to https://example.com/, got %s", rs.Host)
+			}
+			if rs.OrgRepo != "team/config.git" {
+				t.Errorf("expected OrgRepo rewritten to team/config.git, got %s", rs.OrgRepo)
+			}
+			if rs.Path != "overlays/prod" {
+				t.Errorf("expected Path rewritten to overlays/prod, got %s", rs.Path)
+			}
+		})
+	}
+}
+
+func TestNewRepoSpecFromUrl_VCS(t *testing.T) {
+	testcases := []struct {
+		input string
+		vcs   VCS
+	}{
+		{"https://github.com/someorg/somerepo", Git},
+		{"hg::https://example.com/someorg/somerepo", Hg},
+		{"svn::https://example.com/someorg/somerepo", SVN},
+		{"bzr::https://example.com/someorg/somerepo", Bzr},
+		{"fossil::https://example.com/someorg/somerepo", Fossil},
+		{"https://example.com/someorg/somerepo.hg", Hg},
+		{"https://example.com/my.hgstuff/somerepo", Git},
+	}
+	for _, testcase := range testcases {
+		rs, err := NewRepoSpecFromUrl(testcase.input)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			continue
+		}
+		if rs.VCS != testcase.vcs {
+			t.Errorf("VCS expected to be %v, but got %v on %s", testcase.vcs, rs.VCS, testcase.input)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// orgRepoNoSuffix is OrgRepo with any clone-url suffix (e.g. ".git")
+// trimmed off, since web UIs address repos without it.
+func (x *RepoSpec) orgRepoNoSuffix() string {
+	return strings.TrimSuffix(x.OrgRepo, x.vcsSuffix())
+}
+
+// ref returns the checked-out ref, defaulting to "master" when the
+// RepoSpec didn't pin one (matching git's historical default branch
+// name, since that's what an unpinned base resolves to on clone).
+func (x *RepoSpec) ref() string {
+	if x.Ref == "" {
+		return "master"
+	}
+	return x.Ref
+}
+
+// FileURL renders a browser url for relPath inside the checkout, or ""
+// if x.Host isn't one of the hosts kustomize knows how to link into.
+func (x *RepoSpec) FileURL(relPath string) string {
+	return x.LineURL(relPath, 0)
+}
+
+// LineURL is like FileURL but anchors the browser at a specific line.
+// A line of 0 omits the anchor. AWS CodeCommit urls never carry a line
+// anchor.
+func (x *RepoSpec) LineURL(relPath string, line int) string {
+	orgRepo := x.orgRepoNoSuffix()
+	ref := x.ref()
+	path := filepath.ToSlash(relPath)
+	switch {
+	case strings.Contains(x.Host, "github.com"):
+		u := fmt.Sprintf("https://github.com/%s/blob/%s/%s", orgRepo, ref, path)
+		if line > 0 {
+			u += fmt.Sprintf("#L%d", line)
+		}
+		return u
+	case strings.Contains(x.Host, "gitlab.com"):
+		u := fmt.Sprintf("https://gitlab.com/%s/-/blob/%s/%s", orgRepo, ref, path)
+		if line > 0 {
+			u += fmt.Sprintf("#L%d", line)
+		}
+		return u
+	case strings.Contains(x.Host, "bitbucket.org"):
+		u := fmt.Sprintf("https://bitbucket.org/%s/src/%s/%s", orgRepo, ref, path)
+		if line > 0 {
+			u += fmt.Sprintf("#lines-%d", line)
+		}
+		return u
+	case isAzureHost(x.Host):
+		u := fmt.Sprintf("%s%s?path=/%s&version=GB%s", x.Host, orgRepo, path, ref)
+		if line > 0 {
+			u += fmt.Sprintf("&line=%d", line)
+		}
+		return u
+	case isAWSHost(x.Host):
+		return fmt.Sprintf("%s%s/browse/refs/heads/%s/--/%s", x.Host, orgRepo, ref, path)
+	default:
+		return ""
+	}
+}
+
+// CommitURL renders a browser url for the commit identified by sha, or
+// "" if x.Host isn't one of the hosts kustomize knows how to link into.
+func (x *RepoSpec) CommitURL(sha string) string {
+	orgRepo := x.orgRepoNoSuffix()
+	switch {
+	case strings.Contains(x.Host, "github.com"):
+		return fmt.Sprintf("https://github.com/%s/commit/%s", orgRepo, sha)
+	case strings.Contains(x.Host, "gitlab.com"):
+		return fmt.Sprintf("https://gitlab.com/%s/-/commit/%s", orgRepo, sha)
+	case strings.Contains(x.Host, "bitbucket.org"):
+		return fmt.Sprintf("https://bitbucket.org/%s/commits/%s", orgRepo, sha)
+	case isAzureHost(x.Host):
+		return fmt.Sprintf("%s%s?version=GC%s", x.Host, orgRepo, sha)
+	case isAWSHost(x.Host):
+		return fmt.Sprintf("%s%s/commit/%s", x.Host, orgRepo, sha)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import "testing"
+
+func TestRepoSpec_LineURL(t *testing.T) {
+	testcases := []struct {
+		input   string
+		relPath string
+		line    int
+		expect  string
+	}{
+		{
+			input:   "https://github.com/someorg/somerepo?ref=v1.0.0",
+			relPath: "kustomization.yaml",
+			line:    7,
+			expect:  "https://github.com/someorg/somerepo/blob/v1.0.0/kustomization.yaml#L7",
+		},
+		{
+			input:   "https://gitlab.com/someorg/somerepo?ref=v1.0.0",
+			relPath: "kustomization.yaml",
+			line:    7,
+			expect:  "https://gitlab.com/someorg/somerepo/-/blob/v1.0.0/kustomization.yaml#L7",
+		},
+		{
+			input:   "https://bitbucket.org/someorg/somerepo?ref=v1.0.0",
+			relPath: "kustomization.yaml",
+			line:    7,
+			expect:  "https://bitbucket.org/someorg/somerepo/src/v1.0.0/kustomization.yaml#lines-7",
+		},
+		{
+			input:   "https://github.com/someorg/somerepo",
+			relPath: "kustomization.yaml",
+			line:    0,
+			expect:  "https://github.com/someorg/somerepo/blob/master/kustomization.yaml",
+		},
+		{
+			input:   "https://git-codecommit.us-east-2.amazonaws.com/someorg/somerepo",
+			relPath: "kustomization.yaml",
+			line:    0,
+			expect:  "https://git-codecommit.us-east-2.amazonaws.com/someorg/somerepo/browse/refs/heads/master/--/kustomization.yaml",
+		},
+		{
+			input:   "git@gitlab2.sqtools.ru:10022/infra/kubernetes/thanos-base.git",
+			relPath: "kustomization.yaml",
+			line:    0,
+			expect:  "",
+		},
+	}
+	for _, testcase := range testcases {
+		rs, err := NewRepoSpecFromUrl(testcase.input)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		actual := rs.LineURL(testcase.relPath, testcase.line)
+		if actual != testcase.expect {
+			t.Errorf("LineURL: expected %q, but got %q on %s", testcase.expect, actual, testcase.input)
+		}
+	}
+}
+
+func TestRepoSpec_CommitURL(t *testing.T) {
+	rs, err := NewRepoSpecFromUrl("https://github.com/someorg/somerepo")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expect := "https://github.com/someorg/somerepo/commit/abc123"
+	if actual := rs.CommitURL("abc123"); actual != expect {
+		t.Errorf("CommitURL: expected %q, but got %q", expect, actual)
+	}
+}
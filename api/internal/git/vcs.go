@@ -0,0 +1,72 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"fmt"
+	"time"
+)
+
+// CloneOptions are hints a Backend may use to fetch less than the
+// whole repo, or to tune how the fetch happens. A backend that can't
+// act on a given field is free to ignore it, since Checkout still
+// runs afterward.
+type CloneOptions struct {
+	// Ref is the branch, tag or commit to fetch, if known up front.
+	Ref string
+
+	// Subdir narrows a fetch to one part of the tree, e.g. Git's
+	// archive fast-path for local clone specs.
+	Subdir string
+
+	// Depth requests a shallow clone of the given history depth.
+	// Zero means a full clone.
+	Depth int
+
+	// SingleBranch requests that only Ref's branch be fetched.
+	SingleBranch bool
+
+	// LFS requests that Git LFS objects be pulled as the last step of
+	// a "git clone", before Checkout runs, so the objects pulled are
+	// those of the branch the clone checked out, not of a ref Checkout
+	// later switches to. Git's archive fast-path does not pull them.
+	LFS bool
+
+	// Timeout bounds each VCS command Clone execs. Zero (or negative)
+	// means the backend's own default.
+	Timeout time.Duration
+}
+
+// Backend captures the handful of operations kustomize needs from a
+// version control system in order to pull in a remote base.
+type Backend interface {
+	// Clone fetches cloneSpec into dir, per opts.
+	Clone(dir, cloneSpec string, opts CloneOptions) error
+
+	// Checkout switches the clone in dir to ref, bounded by timeout.
+	Checkout(dir, ref string, timeout time.Duration) error
+
+	// Submodules fetches any nested checkouts the VCS supports,
+	// bounded by timeout.
+	Submodules(dir string, timeout time.Duration) error
+}
+
+// BackendFor returns the Backend implementation for the given VCS, or
+// an error if the VCS isn't supported yet.
+func BackendFor(vcs VCS) (Backend, error) {
+	switch vcs {
+	case Git:
+		return gitBackend{}, nil
+	case Hg:
+		return hgBackend{}, nil
+	case SVN:
+		return svnBackend{}, nil
+	case Bzr:
+		return bzrBackend{}, nil
+	case Fossil:
+		return fossilBackend{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported vcs %q", vcs)
+	}
+}